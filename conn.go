@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/jrick/wsrpc/v2"
+
+	"github.com/jrick/testnet-cpu-miner/internal/clock"
+)
+
+// dial performs a single dial attempt. It is a variable so tests can
+// substitute a fake dialer.
+var dial = func(ctx context.Context, addr string, tc *tls.Config) (*wsrpc.Client, error) {
+	return wsrpc.Dial(ctx, addr, wsrpc.WithTLSConfig(tc))
+}
+
+const (
+	dialBackoffMin = 500 * time.Millisecond
+	dialBackoffMax = 30 * time.Second
+)
+
+// dialBackoff dials addr, retrying with jittered exponential backoff
+// (500ms doubling up to 30s) until it succeeds, maxRetries attempts have
+// been made (0 means unlimited), or timeout elapses (0 means no timeout).
+func dialBackoff(ctx context.Context, clk clock.Clock, addr string, tc *tls.Config, maxRetries int, timeout time.Duration) (*wsrpc.Client, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	backoff := dialBackoffMin
+	for attempt := 1; ; attempt++ {
+		c, err := dial(ctx, addr, tc)
+		if err == nil {
+			return c, nil
+		}
+		if maxRetries > 0 && attempt >= maxRetries {
+			return nil, fmt.Errorf("dial %s: %w (giving up after %d attempts)", addr, err, attempt)
+		}
+
+		jitter := time.Duration(rand.Int63n(int64(backoff)))
+		wait := backoff/2 + jitter/2
+		log.Printf("dial %s: %v; retrying in %v", addr, err, wait.Truncate(time.Millisecond))
+		if !sleepCtx(ctx, clk, wait) {
+			return nil, ctx.Err()
+		}
+
+		backoff *= 2
+		if backoff > dialBackoffMax {
+			backoff = dialBackoffMax
+		}
+	}
+}
+
+// reconnectingClient holds a live *wsrpc.Client, transparently redialing
+// with the same backoff as the initial connection if the connection is
+// lost, so the mining schedule in run doesn't need to be restarted after a
+// dcrd restart or network blip.
+type reconnectingClient struct {
+	addr        string
+	tc          *tls.Config
+	clk         clock.Clock
+	dialRetries int
+	dialTimeout time.Duration
+
+	mu sync.RWMutex
+	c  *wsrpc.Client
+}
+
+// dialReconnecting performs the initial dial and, on success, starts
+// watching the connection for loss so it can be transparently redialed.
+func dialReconnecting(ctx context.Context, clk clock.Clock, addr string, tc *tls.Config, dialRetries int, dialTimeout time.Duration) (*reconnectingClient, error) {
+	c, err := dialBackoff(ctx, clk, addr, tc, dialRetries, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &reconnectingClient{
+		addr:        addr,
+		tc:          tc,
+		clk:         clk,
+		dialRetries: dialRetries,
+		dialTimeout: dialTimeout,
+		c:           c,
+	}
+	rpcUp.Inc()
+	go rc.watch(ctx, c)
+	return rc, nil
+}
+
+// watch blocks until c's connection is lost, then redials and swaps in the
+// new client, unless ctx has been canceled first.
+func (rc *reconnectingClient) watch(ctx context.Context, c *wsrpc.Client) {
+	<-c.Done()
+	rpcUp.Dec()
+	if ctx.Err() != nil {
+		return
+	}
+
+	log.Printf("rpc connection to %s lost; reconnecting", rc.addr)
+	newClient, err := dialBackoff(ctx, rc.clk, rc.addr, rc.tc, rc.dialRetries, rc.dialTimeout)
+	if err != nil {
+		log.Printf("giving up reconnecting to %s: %v", rc.addr, err)
+		return
+	}
+
+	rc.mu.Lock()
+	rc.c = newClient
+	rc.mu.Unlock()
+	rpcUp.Inc()
+	log.Printf("reconnected to %s", rc.addr)
+
+	go rc.watch(ctx, newClient)
+}
+
+// client returns the current *wsrpc.Client, which may change across calls
+// if the connection has been transparently redialed.
+func (rc *reconnectingClient) client() *wsrpc.Client {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.c
+}
+
+// close closes the current underlying connection.
+func (rc *reconnectingClient) close() error {
+	return rc.client().Close()
+}
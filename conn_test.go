@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jrick/testnet-cpu-miner/internal/clock"
+	"github.com/jrick/wsrpc/v2"
+)
+
+// fakeDialer records each dial attempt and lets a test script successes
+// and failures without a real network.
+type fakeDialer struct {
+	mu       sync.Mutex
+	attempts []time.Time
+	results  []error // nil entries succeed
+}
+
+func (f *fakeDialer) dial(clk clock.Clock) func(context.Context, string, *tls.Config) (*wsrpc.Client, error) {
+	return func(ctx context.Context, addr string, tc *tls.Config) (*wsrpc.Client, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		i := len(f.attempts)
+		f.attempts = append(f.attempts, clk.Now())
+		if i < len(f.results) && f.results[i] != nil {
+			return nil, f.results[i]
+		}
+		return nil, nil
+	}
+}
+
+func (f *fakeDialer) attemptCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.attempts)
+}
+
+func TestDialBackoffRetriesUntilSuccess(t *testing.T) {
+	origDial := dial
+	defer func() { dial = origDial }()
+
+	f := &fakeDialer{results: []error{errors.New("refused"), errors.New("refused"), nil}}
+	clk := clock.NewMock(time.Unix(0, 0))
+	dial = f.dial(clk)
+
+	done := make(chan struct{})
+	go func() {
+		if _, err := dialBackoff(context.Background(), clk, "wss://x", nil, 0, 0); err != nil {
+			t.Errorf("dialBackoff returned error: %v", err)
+		}
+		close(done)
+	}()
+
+	// First attempt happens immediately; then it must back off ~500ms
+	// before the second attempt, and ~1s before the third.
+	waitUntil(t, func() bool { return f.attemptCount() == 1 })
+	waitUntil(t, func() bool { return clk.PendingWaiters() == 1 })
+	clk.Advance(dialBackoffMin)
+	waitUntil(t, func() bool { return f.attemptCount() == 2 })
+	waitUntil(t, func() bool { return clk.PendingWaiters() == 1 })
+	clk.Advance(2 * dialBackoffMin)
+	waitUntil(t, func() bool { return f.attemptCount() == 3 })
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dialBackoff did not return after dialing succeeded")
+	}
+}
+
+func TestDialBackoffGivesUpAfterMaxRetries(t *testing.T) {
+	origDial := dial
+	defer func() { dial = origDial }()
+
+	f := &fakeDialer{results: []error{errors.New("refused"), errors.New("refused"), errors.New("refused")}}
+	clk := clock.NewMock(time.Unix(0, 0))
+	dial = f.dial(clk)
+
+	errCh := make(chan error, 1)
+	go func() { _, err := dialBackoff(context.Background(), clk, "wss://x", nil, 2, 0); errCh <- err }()
+
+	waitUntil(t, func() bool { return f.attemptCount() == 1 })
+	waitUntil(t, func() bool { return clk.PendingWaiters() == 1 })
+	clk.Advance(dialBackoffMin)
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("dialBackoff should have given up after dialRetries attempts")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("dialBackoff did not return after exhausting retries")
+	}
+	if got := f.attemptCount(); got != 2 {
+		t.Fatalf("attempts = %d, want 2 (dialRetries)", got)
+	}
+}
+
+func TestDialBackoffStopsOnContextCancel(t *testing.T) {
+	origDial := dial
+	defer func() { dial = origDial }()
+
+	f := &fakeDialer{results: []error{errors.New("refused")}}
+	clk := clock.NewMock(time.Unix(0, 0))
+	dial = f.dial(clk)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { _, err := dialBackoff(ctx, clk, "wss://x", nil, 0, 0); errCh <- err }()
+
+	waitUntil(t, func() bool { return f.attemptCount() == 1 })
+	waitUntil(t, func() bool { return clk.PendingWaiters() == 1 })
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("dialBackoff should report an error when canceled mid-backoff")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("dialBackoff did not return promptly after context cancellation")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const defaultWS = "wss://localhost:19109/ws"
+
+// stringsFlag collects every occurrence of a repeated flag into a slice,
+// e.g. -ws a -ws b -ws c.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+var (
+	wsAddrs    stringsFlag
+	caFiles    stringsFlag
+	certFiles  stringsFlag
+	keyFiles   stringsFlag
+	configFile = flag.String("config", "", "path to a JSON file listing dcrd endpoints, each with its own TLS material (overrides -ws/-ca/-cert/-key)")
+)
+
+func init() {
+	flag.Var(&wsAddrs, "ws", "websocket endpoint; repeat to drive a fleet of dcrd nodes (default "+defaultWS+")")
+	flag.Var(&caFiles, "ca", "path to a dcrd certificate authority; repeat to pair positionally with -ws, or give once to share across all endpoints")
+	flag.Var(&certFiles, "cert", "path to a client certificate; repeat to pair positionally with -ws, or give once to share across all endpoints")
+	flag.Var(&keyFiles, "key", "path to a client certificate key; repeat to pair positionally with -ws, or give once to share across all endpoints")
+}
+
+// endpointConfig describes one dcrd node to mine against.
+type endpointConfig struct {
+	WS   string `json:"ws"`
+	CA   string `json:"ca"`
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+}
+
+// loadEndpoints builds the endpoint list from -config if given, otherwise
+// from the repeated -ws/-ca/-cert/-key flags.
+func loadEndpoints() ([]endpointConfig, error) {
+	if *configFile != "" {
+		return loadEndpointsFile(*configFile)
+	}
+
+	ws := []string(wsAddrs)
+	if len(ws) == 0 {
+		ws = []string{defaultWS}
+	}
+
+	ca, err := expandPerEndpoint(caFiles, len(ws), "ca")
+	if err != nil {
+		return nil, err
+	}
+	cert, err := expandPerEndpoint(certFiles, len(ws), "cert")
+	if err != nil {
+		return nil, err
+	}
+	key, err := expandPerEndpoint(keyFiles, len(ws), "key")
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := make([]endpointConfig, len(ws))
+	for i := range ws {
+		endpoints[i] = endpointConfig{WS: ws[i], CA: ca[i], Cert: cert[i], Key: key[i]}
+	}
+	return endpoints, nil
+}
+
+func loadEndpointsFile(path string) ([]endpointConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read endpoints config: %w", err)
+	}
+	var endpoints []endpointConfig
+	if err := json.Unmarshal(data, &endpoints); err != nil {
+		return nil, fmt.Errorf("parse endpoints config: %w", err)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("endpoints config %s lists no endpoints", path)
+	}
+	return endpoints, nil
+}
+
+// expandPerEndpoint matches a repeatable flag's values against n
+// endpoints: zero values leaves every endpoint's setting empty, one value
+// is shared by every endpoint, and n values pair positionally with -ws.
+func expandPerEndpoint(vals []string, n int, flagName string) ([]string, error) {
+	switch len(vals) {
+	case 0:
+		return make([]string, n), nil
+	case 1:
+		out := make([]string, n)
+		for i := range out {
+			out[i] = vals[0]
+		}
+		return out, nil
+	case n:
+		return vals, nil
+	default:
+		return nil, fmt.Errorf("-%s given %d times, want 1 or %d to match -ws", flagName, len(vals), n)
+	}
+}
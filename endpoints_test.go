@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandPerEndpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		vals []string
+		n    int
+		want []string
+	}{
+		{"zero values leaves every endpoint empty", nil, 3, []string{"", "", ""}},
+		{"one value is shared by every endpoint", []string{"shared"}, 3, []string{"shared", "shared", "shared"}},
+		{"n values pair positionally", []string{"a", "b", "c"}, 3, []string{"a", "b", "c"}},
+		{"a single endpoint needs only one value", []string{"only"}, 1, []string{"only"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandPerEndpoint(tt.vals, tt.n, "ca")
+			if err != nil {
+				t.Fatalf("expandPerEndpoint returned error: %v", err)
+			}
+			if !equalStrings(got, tt.want) {
+				t.Fatalf("expandPerEndpoint = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandPerEndpointRejectsMismatchedCount(t *testing.T) {
+	_, err := expandPerEndpoint([]string{"a", "b"}, 3, "cert")
+	if err == nil {
+		t.Fatal("expected an error when given neither 1 nor n values")
+	}
+}
+
+func TestLoadEndpointsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.json")
+	const data = `[
+		{"ws": "wss://a", "ca": "a.ca", "cert": "a.cert", "key": "a.key"},
+		{"ws": "wss://b", "ca": "b.ca", "cert": "b.cert", "key": "b.key"}
+	]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadEndpointsFile(path)
+	if err != nil {
+		t.Fatalf("loadEndpointsFile returned error: %v", err)
+	}
+	want := []endpointConfig{
+		{WS: "wss://a", CA: "a.ca", Cert: "a.cert", Key: "a.key"},
+		{WS: "wss://b", CA: "b.ca", Cert: "b.cert", Key: "b.key"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("loadEndpointsFile returned %d endpoints, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("endpoint %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoadEndpointsFileRejectsEmptyList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.json")
+	if err := os.WriteFile(path, []byte(`[]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadEndpointsFile(path); err == nil {
+		t.Fatal("expected an error for a config file listing no endpoints")
+	}
+}
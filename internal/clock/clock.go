@@ -0,0 +1,54 @@
+// Package clock abstracts time so callers can swap the real clock for a
+// simulated one in tests.
+package clock
+
+import "time"
+
+// Timer mirrors the subset of time.Timer used by this package's clients.
+type Timer interface {
+	// C returns the channel on which the time is delivered when the
+	// timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, as time.Timer.Stop does.
+	Stop() bool
+}
+
+// Clock abstracts time.Now, time.Sleep, time.NewTimer, and friends so
+// scheduling logic can be tested with a simulated clock instead of waiting
+// on wall time.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Until(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	NewTimer(d time.Duration) Timer
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is a Clock backed by the stdlib time package.
+type Real struct{}
+
+// Now implements Clock.
+func (Real) Now() time.Time { return time.Now() }
+
+// Since implements Clock.
+func (Real) Since(t time.Time) time.Duration { return time.Since(t) }
+
+// Until implements Clock.
+func (Real) Until(t time.Time) time.Duration { return time.Until(t) }
+
+// Sleep implements Clock.
+func (Real) Sleep(d time.Duration) { time.Sleep(d) }
+
+// NewTimer implements Clock.
+func (Real) NewTimer(d time.Duration) Timer { return &realTimer{time.NewTimer(d)} }
+
+// After implements Clock.
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r *realTimer) C() <-chan time.Time { return r.t.C }
+func (r *realTimer) Stop() bool          { return r.t.Stop() }
@@ -0,0 +1,83 @@
+package clock
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMockAdvanceFiresDueWaiters(t *testing.T) {
+	m := NewMock(time.Unix(0, 0))
+
+	var mu sync.Mutex
+	var fired []string
+	record := func(name string, d time.Duration) {
+		go func() {
+			m.Sleep(d)
+			mu.Lock()
+			fired = append(fired, name)
+			mu.Unlock()
+		}()
+	}
+	snapshot := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), fired...)
+	}
+
+	record("short", time.Second)
+	record("long", 10*time.Second)
+
+	for m.PendingWaiters() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	m.Advance(time.Second)
+	deadline := time.Now().Add(time.Second)
+	for len(snapshot()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := snapshot(); len(got) != 1 || got[0] != "short" {
+		t.Fatalf("after advancing 1s, fired = %v, want [short]", got)
+	}
+
+	m.Advance(9 * time.Second)
+	deadline = time.Now().Add(time.Second)
+	for len(snapshot()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := snapshot(); len(got) != 2 || got[1] != "long" {
+		t.Fatalf("after advancing 10s total, fired = %v, want [short long]", got)
+	}
+}
+
+func TestMockNewTimerStop(t *testing.T) {
+	m := NewMock(time.Unix(0, 0))
+	timer := m.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Fatal("Stop on a pending timer should report true")
+	}
+	if m.PendingWaiters() != 0 {
+		t.Fatalf("PendingWaiters = %d, want 0 after Stop", m.PendingWaiters())
+	}
+
+	m.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+func TestMockSinceUntil(t *testing.T) {
+	base := time.Unix(1000, 0)
+	m := NewMock(base)
+
+	if got := m.Until(base.Add(5 * time.Second)); got != 5*time.Second {
+		t.Fatalf("Until = %v, want 5s", got)
+	}
+	m.Advance(5 * time.Second)
+	if got := m.Since(base); got != 5*time.Second {
+		t.Fatalf("Since = %v, want 5s", got)
+	}
+}
@@ -0,0 +1,121 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Mock is a Clock whose virtual time only moves when Advance is called.
+// It is safe for concurrent use. The zero value starts at the zero
+// time.Time (January 1, year 1, UTC); use NewMock to start at a specific
+// time.
+type Mock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*mockWaiter
+}
+
+// mockWaiter is a pending Sleep, NewTimer, or After call.
+type mockWaiter struct {
+	fire time.Time
+	c    chan time.Time
+}
+
+// NewMock returns a Mock clock whose current time is now.
+func NewMock(now time.Time) *Mock {
+	return &Mock{now: now}
+}
+
+// Now implements Clock.
+func (m *Mock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// Since implements Clock.
+func (m *Mock) Since(t time.Time) time.Duration {
+	return m.Now().Sub(t)
+}
+
+// Until implements Clock.
+func (m *Mock) Until(t time.Time) time.Duration {
+	return t.Sub(m.Now())
+}
+
+// Sleep blocks until a test calls Advance by at least d.
+func (m *Mock) Sleep(d time.Duration) {
+	<-m.After(d)
+}
+
+// NewTimer returns a Timer that fires when a test calls Advance by at
+// least d.
+func (m *Mock) NewTimer(d time.Duration) Timer {
+	return &mockTimer{m: m, w: m.newWaiter(d)}
+}
+
+// After returns a channel that receives the fire time when a test calls
+// Advance by at least d.
+func (m *Mock) After(d time.Duration) <-chan time.Time {
+	return m.newWaiter(d).c
+}
+
+func (m *Mock) newWaiter(d time.Duration) *mockWaiter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w := &mockWaiter{
+		fire: m.now.Add(d),
+		c:    make(chan time.Time, 1),
+	}
+	m.waiters = append(m.waiters, w)
+	return w
+}
+
+// PendingWaiters reports the number of outstanding Sleep, NewTimer, and
+// After calls. Tests use this to wait until a goroutine under test has
+// registered its wait before calling Advance.
+func (m *Mock) PendingWaiters() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.waiters)
+}
+
+// Advance moves the mock clock forward by d and fires, in registration
+// order, every pending Sleep, NewTimer, or After whose deadline is now at
+// or before the new time.
+func (m *Mock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.now = m.now.Add(d)
+
+	remaining := m.waiters[:0]
+	for _, w := range m.waiters {
+		if !w.fire.After(m.now) {
+			w.c <- m.now
+		} else {
+			remaining = append(remaining, w)
+		}
+	}
+	m.waiters = remaining
+}
+
+type mockTimer struct {
+	m *Mock
+	w *mockWaiter
+}
+
+func (t *mockTimer) C() <-chan time.Time { return t.w.c }
+
+func (t *mockTimer) Stop() bool {
+	t.m.mu.Lock()
+	defer t.m.mu.Unlock()
+	for i, w := range t.m.waiters {
+		if w == t.w {
+			t.m.waiters = append(t.m.waiters[:i], t.m.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
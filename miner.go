@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/jrick/wsrpc/v2"
+
+	"github.com/jrick/testnet-cpu-miner/internal/clock"
 )
 
 func init() {
@@ -18,12 +20,13 @@ func init() {
 }
 
 var (
-	targetBlockTime = flag.Duration("blocktime", 2*time.Minute, "target block duration")
-	retryDuration   = flag.Duration("retry", 30*time.Second, "duration to wait before retries after errors")
-	ws              = flag.String("ws", "wss://localhost:19109/ws", "websocket endpoint")
-	ca              = flag.String("ca", "", "path to dcrd certificate authority")
-	clientCert      = flag.String("cert", "", "path to client certificate")
-	clientKey       = flag.String("key", "", "path to client certificate key")
+	targetBlockTime  = flag.Duration("blocktime", 2*time.Minute, "target block duration")
+	retryDuration    = flag.Duration("retry", 30*time.Second, "duration to wait before retries after errors")
+	dialTimeout      = flag.Duration("dial-timeout", 0, "give up connecting to the RPC server after this long (0 waits forever)")
+	dialRetries      = flag.Int("dial-retries", 0, "give up connecting to the RPC server after this many attempts (0 retries forever)")
+	healthInterval   = flag.Duration("health-interval", 10*time.Second, "interval between endpoint health checks")
+	failoverCooldown = flag.Duration("failover-cooldown", time.Minute, "how long a failed endpoint is skipped before being retried")
+	metricsAddr      = flag.String("metrics-addr", "", "address for an HTTP server exposing Prometheus metrics and /healthz (disabled if empty)")
 )
 
 func pollBestBlockTime(ctx context.Context, c *wsrpc.Client) (t time.Time, err error) {
@@ -49,104 +52,173 @@ func pollBestBlockTime(ctx context.Context, c *wsrpc.Client) (t time.Time, err e
 	return time.Unix(blockHeader.Time, 0), nil
 }
 
-func generate(ctx context.Context, c *wsrpc.Client) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, 16*time.Second)
+// generateStop identifies why generateOnce stopped waiting on an
+// outstanding generate call before it completed on its own.
+type generateStop int
+
+const (
+	generateDone generateStop = iota
+	generateStopTimeout
+	generateStopShutdown
+)
+
+// waitGenerate blocks until done receives a value, timeout elapses on clk,
+// or ctx is canceled, whichever happens first. It is generic over done's
+// element type so it can be used directly with wsrpc.Call's Done()
+// channel as well as a plain chan struct{} in tests.
+func waitGenerate[T any](ctx context.Context, clk clock.Clock, done <-chan T, timeout time.Duration) generateStop {
+	t := clk.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-done:
+		return generateDone
+	case <-t.C():
+		return generateStopTimeout
+	case <-ctx.Done():
+		return generateStopShutdown
+	}
+}
+
+func generate(ctx context.Context, c *wsrpc.Client, clk clock.Clock) (string, error) {
+	start := clk.Now()
+	hash, stop, err := generateOnce(ctx, c, clk)
+	generateDuration.Observe(clk.Since(start).Seconds())
+	if err != nil {
+		reason := "rpc_error"
+		switch stop {
+		case generateStopTimeout:
+			reason = "timeout"
+		case generateStopShutdown:
+			reason = "shutdown"
+		}
+		generateErrors.WithLabelValues(reason).Inc()
+		return "", err
+	}
+	blocksGenerated.Inc()
+	return hash, nil
+}
+
+// generateOnce starts a generate call and waits for it to finish. If ctx
+// is canceled before the call completes (service shutdown) or the call
+// takes longer than 15s, it issues a best-effort "generate 0" to disable
+// the CPU miner even though the original call is still outstanding, then
+// waits for that outstanding call to unwind.
+//
+// The call itself runs on a context derived from ctx with its
+// cancellation stripped, so that a shutdown signal doesn't silently abort
+// the call out from under us before we get a chance to disable the miner.
+func generateOnce(ctx context.Context, c *wsrpc.Client, clk clock.Clock) (hash string, stop generateStop, err error) {
+	callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 16*time.Second)
 	defer cancel()
 
 	log.Print("starting cpu miner")
 
 	var hashes []string
-	call := c.Go(ctx, "generate", &hashes, nil, 1)
-
-	t := time.NewTimer(15 * time.Second)
-	defer t.Stop()
-	var stopped bool
-	select {
-	case <-call.Done():
-	case <-t.C:
-		log.Print("stopping cpu miner")
+	call := c.Go(callCtx, "generate", &hashes, nil, 1)
 
-		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
-		err := c.Call(ctx, "generate", nil, 0)
-		cancel()
-		if err != nil {
-			log.Printf("failed to disable CPU miner: %v", err)
-			break
+	stop = waitGenerate(ctx, clk, call.Done(), 15*time.Second)
+	if stop != generateDone {
+		if stop == generateStopShutdown {
+			log.Print("shutting down: stopping cpu miner")
+		} else {
+			log.Print("stopping cpu miner")
 		}
-		stopped = true
 
-		<-call.Done()
+		stopCtx, stopCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		stopErr := c.Call(stopCtx, "generate", nil, 0)
+		stopCancel()
+		if stopErr != nil {
+			log.Printf("failed to disable CPU miner: %v", stopErr)
+		} else {
+			<-call.Done()
+		}
 	}
 
-	_, err := call.Result()
+	_, err = call.Result()
 	if err != nil {
 		// Log unexpected errors not caused by stopping the miner.
-		if !stopped {
+		if stop == generateDone {
 			log.Printf("generate: %v", err)
 		}
-		return "", err
+		return "", stop, err
 	}
-	return hashes[0], nil
+	return hashes[0], generateDone, nil
 }
 
-func setupTLS() *tls.Config {
+func setupTLS(caFile, certFile, keyFile string) (*tls.Config, error) {
 	caPool := x509.NewCertPool()
-	caCerts, err := os.ReadFile(*ca)
+	caCerts, err := os.ReadFile(caFile)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("read CA file: %w", err)
 	}
 	if !caPool.AppendCertsFromPEM(caCerts) {
-		log.Fatal("no certificates found in CA file")
+		return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
 	}
 
-	keypair, err := tls.LoadX509KeyPair(*clientCert, *clientKey)
+	keypair, err := tls.LoadX509KeyPair(certFile, keyFile)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("load client keypair: %w", err)
 	}
 
 	return &tls.Config{
 		Certificates: []tls.Certificate{keypair},
 		RootCAs:      caPool,
-	}
+	}, nil
 }
 
-func main() {
-	flag.Parse()
-
-	ctx := context.Background()
-
-	tc := setupTLS()
-
-	// Hacky, but should probably be long enough to bring up RPC.
-	// Exact timing of when we get to work is not important.
-	log.Printf("connecting RPC in %v", *targetBlockTime)
-	time.Sleep(*targetBlockTime)
-
-	c, err := wsrpc.Dial(ctx, *ws, wsrpc.WithTLSConfig(tc))
-	if err != nil {
-		log.Fatal(err)
+// sleepCtx sleeps for d on clk, returning early with false if ctx is
+// canceled first.
+func sleepCtx(ctx context.Context, clk clock.Clock, d time.Duration) bool {
+	if d <= 0 {
+		return true
 	}
+	t := clk.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
 
+// run is the mining scheduling loop. It polls the best block time, waits
+// until it is time to mine, generates a block, and sleeps until the next
+// poll, repeating until ctx is canceled. poll and gen are passed in so the
+// loop's timing logic can be exercised in tests without a real RPC client.
+func run(ctx context.Context, clk clock.Clock, poll func(context.Context) (time.Time, error), gen func(context.Context) (string, error)) {
 	for {
-		tipTime, err := pollBestBlockTime(ctx, c)
+		if ctx.Err() != nil {
+			return
+		}
+
+		tipTime, err := poll(ctx)
 		if err != nil {
+			pollErrors.Inc()
 			log.Printf("warn: pollBestBlockTime returned error: %v", err)
-			time.Sleep(*retryDuration)
+			sleepSeconds.Observe((*retryDuration).Seconds())
+			if !sleepCtx(ctx, clk, *retryDuration) {
+				return
+			}
 			continue
 		}
+		tipAge.Set(clk.Since(tipTime).Seconds())
 
 		mineTime := tipTime.Add(*targetBlockTime)
 		log.Printf("best block time: %v; mining scheduled time: %v", tipTime, mineTime)
 
-		if wait := time.Until(mineTime); wait > 0 {
+		if wait := clk.Until(mineTime); wait > 0 {
 			log.Printf("sleeping %v", wait.Truncate(time.Millisecond))
-			time.Sleep(wait)
+			sleepSeconds.Observe(wait.Seconds())
+			if !sleepCtx(ctx, clk, wait) {
+				return
+			}
 			continue
 		}
 
 		var sleep time.Duration
 
-		hash, err := generate(ctx, c)
+		hash, err := gen(ctx)
 		if err != nil {
 			sleep = min(*retryDuration, *targetBlockTime)
 		} else {
@@ -155,8 +227,56 @@ func main() {
 			sleep = *targetBlockTime
 		}
 
-		nextPoll := time.Now().Add(sleep).Truncate(100 * time.Millisecond)
+		nextPoll := clk.Now().Add(sleep).Truncate(100 * time.Millisecond)
 		log.Printf("polling blocks again at %v", nextPoll)
-		time.Sleep(sleep)
+		sleepSeconds.Observe(sleep.Seconds())
+		if !sleepCtx(ctx, clk, sleep) {
+			return
+		}
+	}
+}
+
+func main() {
+	os.Exit(realMain())
+}
+
+// realMain runs the miner to completion and returns the process exit
+// code: 0 for a clean shutdown (SIGINT/SIGTERM), non-zero if it couldn't
+// start or stopped abnormally.
+func realMain() int {
+	flag.Parse()
+
+	svc, ctx := NewService()
+	clk := clock.Real{}
+
+	if *metricsAddr != "" {
+		serveMetrics(*metricsAddr)
+	}
+
+	endpoints, err := loadEndpoints()
+	if err != nil {
+		log.Printf("error: %v", err)
+		return 1
+	}
+
+	pool, err := newPool(ctx, clk, endpoints, *dialRetries, *dialTimeout, *healthInterval, *failoverCooldown)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return 1
+	}
+	defer pool.Close()
+
+	svc.Start(func() error {
+		run(ctx, clk,
+			pool.pollBestBlockTime,
+			func(ctx context.Context) (string, error) { return pool.generate(ctx, clk) },
+		)
+		return nil
+	})
+
+	if err := svc.Wait(); err != nil {
+		log.Printf("error: %v", err)
+		return 1
 	}
+	return 0
 }
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jrick/testnet-cpu-miner/internal/clock"
+)
+
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWaitGenerateReturnsOnCompletion(t *testing.T) {
+	m := clock.NewMock(time.Unix(0, 0))
+	done := make(chan struct{})
+	close(done)
+
+	if got := waitGenerate(context.Background(), m, done, 15*time.Second); got != generateDone {
+		t.Fatalf("waitGenerate = %v, want generateDone for an already-completed call", got)
+	}
+}
+
+func TestWaitGenerateFiresAfter15s(t *testing.T) {
+	m := clock.NewMock(time.Unix(0, 0))
+	done := make(chan struct{}) // never closed: simulates a block that never appears
+
+	result := make(chan generateStop, 1)
+	go func() { result <- waitGenerate(context.Background(), m, done, 15*time.Second) }()
+
+	waitUntil(t, func() bool { return m.PendingWaiters() == 1 })
+
+	m.Advance(14 * time.Second)
+	select {
+	case <-result:
+		t.Fatal("waitGenerate returned before its 15s deadline")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	m.Advance(time.Second)
+	select {
+	case stop := <-result:
+		if stop != generateStopTimeout {
+			t.Fatalf("waitGenerate = %v, want generateStopTimeout", stop)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waitGenerate did not return after its deadline elapsed")
+	}
+}
+
+func TestWaitGenerateStopsOnContextCancel(t *testing.T) {
+	m := clock.NewMock(time.Unix(0, 0))
+	done := make(chan struct{}) // never closed
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan generateStop, 1)
+	go func() { result <- waitGenerate(ctx, m, done, 15*time.Second) }()
+
+	waitUntil(t, func() bool { return m.PendingWaiters() == 1 })
+	cancel()
+
+	select {
+	case stop := <-result:
+		if stop != generateStopShutdown {
+			t.Fatalf("waitGenerate = %v, want generateStopShutdown", stop)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waitGenerate did not return promptly after context cancellation")
+	}
+}
+
+// runHarness drives run with a mock clock and counts calls so tests can
+// assert on the scheduling decisions it makes.
+type runHarness struct {
+	clk *clock.Mock
+
+	mu        sync.Mutex
+	polls     int
+	pollErr   error
+	tipTime   time.Time
+	generates int
+	genErr    error
+}
+
+func newRunHarness(now time.Time) *runHarness {
+	return &runHarness{clk: clock.NewMock(now), tipTime: now}
+}
+
+func (h *runHarness) poll(context.Context) (time.Time, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.polls++
+	if h.pollErr != nil {
+		return time.Time{}, h.pollErr
+	}
+	return h.tipTime, nil
+}
+
+func (h *runHarness) generate(context.Context) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.generates++
+	if h.genErr != nil {
+		return "", h.genErr
+	}
+	return "deadbeef", nil
+}
+
+func (h *runHarness) pollCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.polls
+}
+
+func (h *runHarness) generateCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.generates
+}
+
+func TestRunSkipsMiningUntilMineTime(t *testing.T) {
+	origBlockTime, origRetry := *targetBlockTime, *retryDuration
+	*targetBlockTime = time.Minute
+	*retryDuration = time.Second
+	defer func() { *targetBlockTime, *retryDuration = origBlockTime, origRetry }()
+
+	now := time.Unix(1000, 0)
+	h := newRunHarness(now) // tip is "now", so mineTime is one minute out
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		run(ctx, h.clk, h.poll, h.generate)
+		close(done)
+	}()
+
+	waitUntil(t, func() bool { return h.pollCount() >= 1 })
+	waitUntil(t, func() bool { return h.clk.PendingWaiters() == 1 })
+	if h.generateCount() != 0 {
+		t.Fatal("generate should not run before mineTime")
+	}
+
+	h.clk.Advance(time.Minute)
+	waitUntil(t, func() bool { return h.generateCount() >= 1 })
+
+	cancel()
+	<-done
+}
+
+func TestRunHonorsRetryDurationOnPollError(t *testing.T) {
+	origBlockTime, origRetry := *targetBlockTime, *retryDuration
+	*targetBlockTime = time.Minute
+	*retryDuration = 5 * time.Second
+	defer func() { *targetBlockTime, *retryDuration = origBlockTime, origRetry }()
+
+	h := newRunHarness(time.Unix(2000, 0))
+	h.pollErr = errors.New("rpc unavailable")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		run(ctx, h.clk, h.poll, h.generate)
+		close(done)
+	}()
+
+	waitUntil(t, func() bool { return h.pollCount() >= 1 })
+	waitUntil(t, func() bool { return h.clk.PendingWaiters() == 1 })
+
+	// Advancing by less than retryDuration must not trigger another poll.
+	h.clk.Advance(4 * time.Second)
+	time.Sleep(20 * time.Millisecond)
+	if h.pollCount() != 1 {
+		t.Fatalf("poll count = %d after 4s, want 1 (retryDuration is 5s)", h.pollCount())
+	}
+
+	h.clk.Advance(time.Second)
+	waitUntil(t, func() bool { return h.pollCount() >= 2 })
+
+	cancel()
+	<-done
+}
+
+func TestRunShutsDownPromptlyDuringSleep(t *testing.T) {
+	origBlockTime, origRetry := *targetBlockTime, *retryDuration
+	*targetBlockTime = time.Hour
+	*retryDuration = time.Hour
+	defer func() { *targetBlockTime, *retryDuration = origBlockTime, origRetry }()
+
+	h := newRunHarness(time.Unix(3000, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		run(ctx, h.clk, h.poll, h.generate)
+		close(done)
+	}()
+
+	waitUntil(t, func() bool { return h.clk.PendingWaiters() == 1 })
+
+	start := time.Now()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("run did not shut down promptly after ctx cancellation")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("shutdown took %v, want well under the 1h mock sleep", elapsed)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jrick/wsrpc/v2"
+
+	"github.com/jrick/testnet-cpu-miner/internal/clock"
+)
+
+// poolPeer is one dcrd endpoint in a Pool, along with the health state
+// used to rank it as a failover candidate. rc is nil until the endpoint's
+// initial dial completes, which happens in the background so a down
+// endpoint can't delay the rest of the pool.
+type poolPeer struct {
+	ws string
+
+	mu             sync.Mutex
+	rc             *reconnectingClient
+	connected      bool      // set once rc's first successful ping lands
+	unhealthyUntil time.Time // zero means healthy
+	height         int64
+	lastResponse   time.Time
+}
+
+// snapshot returns peer's health as of now: whether it should currently be
+// considered for leadership, its last known best-block height, and the
+// time of its last successful response. A peer that has never completed a
+// successful ping (including one whose initial dial is still retrying in
+// the background) is never healthy.
+func (peer *poolPeer) snapshot(now time.Time) (healthy bool, height int64, last time.Time) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	healthy = peer.connected && (peer.unhealthyUntil.IsZero() || !peer.unhealthyUntil.After(now))
+	return healthy, peer.height, peer.lastResponse
+}
+
+// client returns peer's current *wsrpc.Client, or nil if its initial dial
+// hasn't completed yet.
+func (peer *poolPeer) client() *wsrpc.Client {
+	peer.mu.Lock()
+	rc := peer.rc
+	peer.mu.Unlock()
+	if rc == nil {
+		return nil
+	}
+	return rc.client()
+}
+
+func (peer *poolPeer) markHealthy(now time.Time, height int64) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	peer.connected = true
+	peer.unhealthyUntil = time.Time{}
+	peer.height = height
+	peer.lastResponse = now
+}
+
+func (peer *poolPeer) markUnhealthy(until time.Time) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	peer.unhealthyUntil = until
+}
+
+// Pool owns one reconnectingClient per configured dcrd endpoint, pings
+// each periodically to track its health and best-block height, and routes
+// calls to the current leader: the healthy endpoint with the greatest
+// height, breaking ties by the most recently responsive.
+type Pool struct {
+	clk      clock.Clock
+	cooldown time.Duration
+	peers    []*poolPeer
+}
+
+// newPool registers every endpoint and dials them concurrently in the
+// background, so one endpoint that's down at startup (e.g. restarting or
+// upgrading) doesn't delay or prevent the others from coming up. Endpoints
+// are unhealthy until their initial dial succeeds. newPool only fails for
+// immediate configuration problems (a bad TLS setup); dial retries for an
+// unreachable endpoint continue in the background until they succeed, ctx
+// is canceled, or dialRetries or dialTimeout is exhausted, after which that
+// endpoint stays unhealthy for the lifetime of the Pool.
+func newPool(ctx context.Context, clk clock.Clock, endpoints []endpointConfig, dialRetries int, dialTimeout, healthInterval, cooldown time.Duration) (*Pool, error) {
+	p := &Pool{clk: clk, cooldown: cooldown}
+	for _, e := range endpoints {
+		tc, err := setupTLS(e.CA, e.Cert, e.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.WS, err)
+		}
+
+		peer := &poolPeer{ws: e.WS}
+		p.peers = append(p.peers, peer)
+
+		go p.connect(ctx, peer, e.WS, tc, dialRetries, dialTimeout, healthInterval)
+	}
+	return p, nil
+}
+
+// connect dials ws in the background, retrying with backoff until it
+// succeeds, ctx is canceled, or dialRetries or dialTimeout is exhausted,
+// then, if the dial succeeded, starts peer's health-check loop. It
+// runs concurrently with the other endpoints' connect goroutines so a
+// single unreachable endpoint never blocks the rest of the pool.
+func (p *Pool) connect(ctx context.Context, peer *poolPeer, ws string, tc *tls.Config, dialRetries int, dialTimeout, healthInterval time.Duration) {
+	rc, err := dialReconnecting(ctx, p.clk, ws, tc, dialRetries, dialTimeout)
+	if err != nil {
+		log.Printf("giving up connecting to %s: %v", ws, err)
+		return
+	}
+
+	peer.mu.Lock()
+	peer.rc = rc
+	peer.mu.Unlock()
+
+	p.healthLoop(ctx, peer, healthInterval)
+}
+
+func (p *Pool) healthLoop(ctx context.Context, peer *poolPeer, interval time.Duration) {
+	p.ping(ctx, peer)
+	for {
+		if !sleepCtx(ctx, p.clk, interval) {
+			return
+		}
+		p.ping(ctx, peer)
+	}
+}
+
+// ping probes peer with the same getbestblockhash/getblockheader pair
+// pollBestBlockTime uses, recording its height on success or marking it
+// unhealthy for p.cooldown on failure.
+func (p *Pool) ping(ctx context.Context, peer *poolPeer) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	c := peer.client()
+
+	var bestHash string
+	if err := c.Call(ctx, "getbestblockhash", &bestHash); err != nil {
+		peer.markUnhealthy(p.clk.Now().Add(p.cooldown))
+		return
+	}
+
+	var header struct {
+		Height int64 `json:"height"`
+	}
+	if err := c.Call(ctx, "getblockheader", &header, bestHash); err != nil {
+		peer.markUnhealthy(p.clk.Now().Add(p.cooldown))
+		return
+	}
+
+	peer.markHealthy(p.clk.Now(), header.Height)
+}
+
+// leader picks the best healthy peer not in excluded.
+func (p *Pool) leader(excluded map[*poolPeer]bool) (*poolPeer, error) {
+	now := p.clk.Now()
+
+	var best *poolPeer
+	var bestHeight int64
+	var bestLast time.Time
+	for _, peer := range p.peers {
+		if excluded[peer] {
+			continue
+		}
+		healthy, height, last := peer.snapshot(now)
+		if !healthy {
+			continue
+		}
+		if best == nil || height > bestHeight || (height == bestHeight && last.After(bestLast)) {
+			best, bestHeight, bestLast = peer, height, last
+		}
+	}
+	if best == nil {
+		return nil, errors.New("no healthy dcrd endpoints available")
+	}
+	return best, nil
+}
+
+// call runs fn against the current leader. If fn fails, the leader is
+// marked unhealthy for p.cooldown and the call fails over to the next
+// healthy peer, until one succeeds or none remain.
+func (p *Pool) call(ctx context.Context, fn func(context.Context, *poolPeer) error) error {
+	excluded := make(map[*poolPeer]bool)
+	for {
+		peer, err := p.leader(excluded)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(ctx, peer); err != nil {
+			peer.markUnhealthy(p.clk.Now().Add(p.cooldown))
+			excluded[peer] = true
+			continue
+		}
+		return nil
+	}
+}
+
+func (p *Pool) pollBestBlockTime(ctx context.Context) (t time.Time, err error) {
+	err = p.call(ctx, func(ctx context.Context, peer *poolPeer) error {
+		var e error
+		t, e = pollBestBlockTime(ctx, peer.client())
+		return e
+	})
+	return t, err
+}
+
+func (p *Pool) generate(ctx context.Context, clk clock.Clock) (hash string, err error) {
+	err = p.call(ctx, func(ctx context.Context, peer *poolPeer) error {
+		var e error
+		hash, e = generate(ctx, peer.client(), clk)
+		return e
+	})
+	return hash, err
+}
+
+// Close closes every endpoint's underlying RPC connection. Call it after
+// the run loop has stopped, during shutdown. Endpoints that never finished
+// their initial dial have nothing to close.
+func (p *Pool) Close() {
+	for _, peer := range p.peers {
+		peer.mu.Lock()
+		rc := peer.rc
+		peer.mu.Unlock()
+		if rc == nil {
+			continue
+		}
+		if err := rc.close(); err != nil {
+			log.Printf("closing %s: %v", peer.ws, err)
+		}
+	}
+}
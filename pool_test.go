@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jrick/testnet-cpu-miner/internal/clock"
+)
+
+func newTestPeer(ws string) *poolPeer {
+	return &poolPeer{ws: ws, connected: true}
+}
+
+func TestPoolLeaderPrefersHealthiestHighestPeer(t *testing.T) {
+	clk := clock.NewMock(time.Unix(0, 0))
+	p := &Pool{clk: clk, cooldown: time.Minute}
+
+	low := newTestPeer("low")
+	low.markHealthy(clk.Now(), 100)
+	high := newTestPeer("high")
+	high.markHealthy(clk.Now(), 200)
+	down := newTestPeer("down")
+	down.markUnhealthy(clk.Now().Add(time.Hour))
+	p.peers = []*poolPeer{low, high, down}
+
+	got, err := p.leader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != high {
+		t.Fatalf("leader = %s, want high", got.ws)
+	}
+}
+
+func TestPoolLeaderBreaksTiesByRecency(t *testing.T) {
+	clk := clock.NewMock(time.Unix(0, 0))
+	p := &Pool{clk: clk, cooldown: time.Minute}
+
+	stale := newTestPeer("stale")
+	stale.markHealthy(clk.Now(), 100)
+	clk.Advance(time.Second)
+	fresh := newTestPeer("fresh")
+	fresh.markHealthy(clk.Now(), 100)
+	p.peers = []*poolPeer{stale, fresh}
+
+	got, err := p.leader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != fresh {
+		t.Fatalf("leader = %s, want fresh", got.ws)
+	}
+}
+
+func TestPoolLeaderTreatsCooldownExpiryAsHealthy(t *testing.T) {
+	clk := clock.NewMock(time.Unix(0, 0))
+	p := &Pool{clk: clk, cooldown: time.Minute}
+
+	peer := newTestPeer("recovering")
+	peer.markUnhealthy(clk.Now().Add(time.Minute))
+	p.peers = []*poolPeer{peer}
+
+	if _, err := p.leader(nil); err == nil {
+		t.Fatal("peer still in cooldown should not be selected")
+	}
+
+	clk.Advance(time.Minute)
+	got, err := p.leader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != peer {
+		t.Fatal("peer past its cooldown should be selected again")
+	}
+}
+
+func TestPoolLeaderErrorsWhenAllUnhealthy(t *testing.T) {
+	clk := clock.NewMock(time.Unix(0, 0))
+	p := &Pool{clk: clk, cooldown: time.Minute}
+	peer := newTestPeer("down")
+	peer.markUnhealthy(clk.Now().Add(time.Hour))
+	p.peers = []*poolPeer{peer}
+
+	if _, err := p.leader(nil); err == nil {
+		t.Fatal("expected an error when every peer is unhealthy")
+	}
+}
+
+func TestPoolCallFailsOverToNextHealthyPeer(t *testing.T) {
+	clk := clock.NewMock(time.Unix(0, 0))
+	p := &Pool{clk: clk, cooldown: time.Minute}
+
+	bad := newTestPeer("bad")
+	bad.markHealthy(clk.Now(), 200) // ranked first by height, but its calls fail
+	good := newTestPeer("good")
+	good.markHealthy(clk.Now(), 100)
+	p.peers = []*poolPeer{bad, good}
+
+	failing := errors.New("rpc unavailable")
+	var tried []string
+	err := p.call(context.Background(), func(ctx context.Context, peer *poolPeer) error {
+		tried = append(tried, peer.ws)
+		if peer == bad {
+			return failing
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("call returned error: %v", err)
+	}
+	if want := []string{"bad", "good"}; !equalStrings(tried, want) {
+		t.Fatalf("tried peers = %v, want %v", tried, want)
+	}
+
+	healthy, _, _ := bad.snapshot(clk.Now())
+	if healthy {
+		t.Fatal("bad peer should be marked unhealthy after a failed call")
+	}
+}
+
+func TestPoolCallErrorsWhenEveryPeerFails(t *testing.T) {
+	clk := clock.NewMock(time.Unix(0, 0))
+	p := &Pool{clk: clk, cooldown: time.Minute}
+
+	peer := newTestPeer("only")
+	peer.markHealthy(clk.Now(), 100)
+	p.peers = []*poolPeer{peer}
+
+	err := p.call(context.Background(), func(ctx context.Context, peer *poolPeer) error {
+		return errors.New("down")
+	})
+	if err == nil {
+		t.Fatal("expected an error when the only peer fails")
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
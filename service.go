@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Service owns the miner's lifecycle: a root context that is canceled on
+// SIGINT/SIGTERM (or an explicit Stop), the background run loop started
+// against that context, and the error it finished with.
+type Service struct {
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	done chan struct{}
+	err  error
+}
+
+// NewService derives a cancellable root context from signal.NotifyContext
+// so SIGINT and SIGTERM request a graceful shutdown instead of killing
+// the process mid-call. Callers should use the returned context for all
+// work that must stop on shutdown.
+func NewService() (*Service, context.Context) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	return &Service{cancel: cancel, done: make(chan struct{})}, ctx
+}
+
+// Start runs fn in the background. fn should return once its context is
+// canceled; its return value is recorded for Wait.
+func (s *Service) Start(fn func() error) {
+	go func() {
+		err := fn()
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		close(s.done)
+	}()
+}
+
+// Stop requests a graceful shutdown by canceling the service's root
+// context, as if a shutdown signal had been received.
+func (s *Service) Stop() {
+	s.cancel()
+}
+
+// Wait blocks until the function passed to Start returns, then reports
+// its error, if any.
+func (s *Service) Wait() error {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestServiceStopCancelsContextAndWait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	svc := &Service{cancel: cancel, done: make(chan struct{})}
+
+	ran := make(chan struct{})
+	svc.Start(func() error {
+		close(ran)
+		<-ctx.Done()
+		return nil
+	})
+
+	waitUntil(t, func() bool {
+		select {
+		case <-ran:
+			return true
+		default:
+			return false
+		}
+	})
+
+	svc.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not cancel the service context")
+	}
+
+	if err := svc.Wait(); err != nil {
+		t.Fatalf("Wait returned %v, want nil", err)
+	}
+}
+
+func TestServiceWaitReportsError(t *testing.T) {
+	_, cancel := context.WithCancel(context.Background())
+	svc := &Service{cancel: cancel, done: make(chan struct{})}
+
+	want := errors.New("abnormal exit")
+	svc.Start(func() error { return want })
+
+	if err := svc.Wait(); err != want {
+		t.Fatalf("Wait = %v, want %v", err, want)
+	}
+}